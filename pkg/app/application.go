@@ -1,8 +1,10 @@
 package app
 
 import (
+	gocontext "context"
 	"errors"
 	"fmt"
+	"github.com/hidevopsio/hiboot/pkg/factory"
 	"github.com/hidevopsio/hiboot/pkg/factory/autoconfigure"
 	"github.com/hidevopsio/hiboot/pkg/factory/instantiate"
 	"github.com/hidevopsio/hiboot/pkg/inject"
@@ -12,9 +14,17 @@ import (
 	"github.com/hidevopsio/hiboot/pkg/utils/io"
 	"github.com/hidevopsio/hiboot/pkg/utils/reflector"
 	"github.com/kataras/iris/context"
+	"os"
+	"os/signal"
 	"reflect"
+	"syscall"
+	"time"
 )
 
+// defaultShutdownTimeout bounds how long a single Stoppable component is
+// given to shut down gracefully during Shutdown
+const defaultShutdownTimeout = 15 * time.Second
+
 type Application interface {
 	Init(args ...interface{}) error
 	Run()
@@ -30,13 +40,77 @@ type PreConfiguration interface{}
 type PostConfiguration interface{}
 
 type BaseApplication struct {
-	WorkDir             string
-	configurations      cmap.ConcurrentMap
-	instances           cmap.ConcurrentMap
-	potatoes            cmap.ConcurrentMap
-	configurableFactory *autoconfigure.ConfigurableFactory
-	systemConfig        *system.Configuration
-	postProcessor       postProcessor
+	WorkDir               string
+	configurations        cmap.ConcurrentMap
+	instances             cmap.ConcurrentMap
+	potatoes              cmap.ConcurrentMap
+	configurableFactory   *autoconfigure.ConfigurableFactory
+	systemConfig          *system.Configuration
+	postProcessor         postProcessor
+	rootCtx               gocontext.Context
+	cancelRoot            gocontext.CancelFunc
+	skippedConfigurations []error
+}
+
+// ComponentOption configures optional properties when registering a component
+type ComponentOption func(*componentOptions)
+
+type componentOptions struct {
+	scope factory.Scope
+}
+
+// Scope sets the lifecycle scope of a component registered via Component,
+// one of "singleton" (the default), "prototype", or "request"
+func Scope(scope string) ComponentOption {
+	return func(o *componentOptions) {
+		o.scope = factory.Scope(scope)
+	}
+}
+
+// Condition gates registration of an AutoConfiguration or Component on some
+// runtime state, similar to Spring Boot's @Conditional
+type Condition = factory.Condition
+
+// ConditionContext exposes the state a Condition is evaluated against
+type ConditionContext = factory.ConditionContext
+
+// OnProperty registers a Condition that matches when the custom property
+// named key resolves to expectedValue
+func OnProperty(key, expectedValue string) Condition {
+	return factory.OnProperty(key, expectedValue)
+}
+
+// OnMissingBean registers a Condition that matches when no component named
+// name has been registered yet, letting a fallback implementation back off
+// once a user supplies their own
+func OnMissingBean(name string) Condition {
+	return factory.OnMissingBean(name)
+}
+
+// OnMissingBeanType registers a Condition that matches when no component of
+// the same type as instance has been registered yet. Use this instead of
+// OnMissingBean when the fallback and the user-supplied bean aren't expected
+// to share a name
+func OnMissingBeanType(instance interface{}) Condition {
+	return factory.OnMissingBeanType(instance)
+}
+
+// OnProfile registers a Condition that matches when app.profiles.active is
+// one of profiles
+func OnProfile(profiles ...string) Condition {
+	return factory.OnProfile(profiles...)
+}
+
+// RequestScopeMiddleware attaches a fresh request scoped instance store to
+// every incoming request, so GetInstance(ctx, name) resolves a "request"
+// scoped bean once per request instead of building a new one on every call.
+// Register it with Use before any controller that depends on such a bean
+func RequestScopeMiddleware() context.Handler {
+	return func(ctx context.Context) {
+		req := ctx.Request()
+		ctx.ResetRequest(req.WithContext(factory.NewRequestContext(req.Context())))
+		ctx.Next()
+	}
 }
 
 var (
@@ -44,11 +118,16 @@ var (
 	configContainer     cmap.ConcurrentMap
 	postConfigContainer cmap.ConcurrentMap
 	instanceContainer   cmap.ConcurrentMap
+	componentScopes     cmap.ConcurrentMap
+	componentConditions cmap.ConcurrentMap
+	configConditions    cmap.ConcurrentMap
 
 	InvalidObjectTypeError        = errors.New("[app] invalid Configuration type, one of app.Configuration, app.PreConfiguration, or app.PostConfiguration need to be embedded")
 	ConfigurationNameIsTakenError = errors.New("[app] configuration name is already taken")
 	ComponentNameIsTakenError     = errors.New("[app] component name is already taken")
 
+	errConfigurationConditionNotMatched = errors.New("[app] condition not matched")
+
 	hideBanner bool
 	banner     = `
 ______  ____________             _____
@@ -65,6 +144,9 @@ func init() {
 	configContainer = cmap.New()
 	postConfigContainer = cmap.New()
 	instanceContainer = cmap.New()
+	componentScopes = cmap.New()
+	componentConditions = cmap.New()
+	configConditions = cmap.New()
 }
 
 func parseInstance(eliminator string, params ...interface{}) (name string, inst interface{}) {
@@ -96,6 +178,23 @@ func AutoConfiguration(params ...interface{}) (err error) {
 		log.Error(err)
 		return
 	}
+
+	var conditions []Condition
+	objParams := make([]interface{}, 0, len(params))
+	for _, p := range params {
+		if cond, ok := p.(Condition); ok {
+			conditions = append(conditions, cond)
+			continue
+		}
+		objParams = append(objParams, p)
+	}
+	if len(objParams) == 0 || objParams[0] == nil {
+		err = InvalidObjectTypeError
+		log.Error(err)
+		return
+	}
+	params = objParams
+
 	name, inst := parseInstance("Configuration", params...)
 	if name == "" || name == "configuration" {
 		name = reflector.ParseObjectPkgName(params[0])
@@ -130,6 +229,9 @@ func AutoConfiguration(params ...interface{}) (err error) {
 	err = validateObjectType(inst)
 	if err == nil {
 		c.Set(name, inst)
+		if len(conditions) > 0 {
+			configConditions.Set(name, conditions)
+		}
 	} else {
 		log.Error(err)
 	}
@@ -137,18 +239,45 @@ func AutoConfiguration(params ...interface{}) (err error) {
 	return err
 }
 
+// ConfigurationConditions returns the Conditions an AutoConfiguration was
+// registered with, or nil if it was registered unconditionally. It is read
+// by BuildConfigurations, which drops any configuration whose Conditions
+// don't match before handing the containers to configurableFactory.Build
+func ConfigurationConditions(name string) []Condition {
+	if v, ok := configConditions.Get(name); ok {
+		return v.([]Condition)
+	}
+	return nil
+}
+
 // Component
 func Component(params ...interface{}) error {
 	if len(params) == 0 || params[0] == nil {
 		return InvalidObjectTypeError
 	}
+
+	options := new(componentOptions)
+	var conditions []Condition
+	objParams := make([]interface{}, 0, len(params))
+	for _, p := range params {
+		if opt, ok := p.(ComponentOption); ok {
+			opt(options)
+			continue
+		}
+		if cond, ok := p.(Condition); ok {
+			conditions = append(conditions, cond)
+			continue
+		}
+		objParams = append(objParams, p)
+	}
+
 	var name string
 	var inst interface{}
-	if len(params) == 2 && reflect.TypeOf(params[0]).Kind() == reflect.String {
-		name = params[0].(string)
-		inst = params[1]
+	if len(objParams) == 2 && reflect.TypeOf(objParams[0]).Kind() == reflect.String {
+		name = objParams[0].(string)
+		inst = objParams[1]
 	} else {
-		inst = params[0]
+		inst = objParams[0]
 		ifcField := reflector.GetEmbeddedInterfaceField(inst)
 		if ifcField.Anonymous {
 			name = ifcField.Name
@@ -161,10 +290,34 @@ func Component(params ...interface{}) error {
 	err := validateObjectType(inst)
 	if err == nil {
 		instanceContainer.Set(name, inst)
+		if options.scope != "" {
+			componentScopes.Set(name, options.scope)
+		}
+		if len(conditions) > 0 {
+			componentConditions.Set(name, conditions)
+		}
 	}
 	return err
 }
 
+// ComponentConditions returns the Conditions a component was registered
+// with via Component(..., cond), or nil if it was registered unconditionally
+func ComponentConditions(name string) []Condition {
+	if v, ok := componentConditions.Get(name); ok {
+		return v.([]Condition)
+	}
+	return nil
+}
+
+// ComponentScope returns the scope a component was registered with via
+// Component(..., Scope(...)), defaulting to factory.ScopeSingleton
+func ComponentScope(name string) factory.Scope {
+	if s, ok := componentScopes.Get(name); ok {
+		return s.(factory.Scope)
+	}
+	return factory.ScopeSingleton
+}
+
 func HideBanner() {
 	hideBanner = true
 }
@@ -179,10 +332,25 @@ func (a *BaseApplication) Init(args ...interface{}) error {
 	a.configurations = cmap.New()
 	a.instances = instanceContainer
 
-	instanceFactory := new(instantiate.InstantiateFactory)
-	instanceFactory.Initialize(a.instances)
+	instanceFactory := instantiate.NewInstantiateFactory(a.instances, nil, cmap.New())
 	a.instances.Set("instanceFactory", instanceFactory)
 
+	// carry every app.Component registration, along with the Scope it was
+	// registered with, into the factory's build list
+	for name, inst := range instanceContainer.Items() {
+		if name == "instanceFactory" || name == "configurableFactory" {
+			continue
+		}
+		componentArgs := []interface{}{name, inst}
+		if scope := ComponentScope(name); scope != factory.ScopeSingleton {
+			componentArgs = append(componentArgs, scope)
+		}
+		if conditions := ComponentConditions(name); len(conditions) > 0 {
+			componentArgs = append(componentArgs, conditions)
+		}
+		instanceFactory.AppendComponent(componentArgs...)
+	}
+
 	configurableFactory := new(autoconfigure.ConfigurableFactory)
 	configurableFactory.InstantiateFactory = instanceFactory
 	a.instances.Set("configurableFactory", configurableFactory)
@@ -197,6 +365,8 @@ func (a *BaseApplication) Init(args ...interface{}) error {
 	a.systemConfig = new(system.Configuration)
 	configurableFactory.BuildSystemConfig(a.systemConfig)
 
+	configurableFactory.InstantiateFactory.SetSystemConfig(a.systemConfig)
+
 	a.configurableFactory = configurableFactory
 
 	return nil
@@ -208,7 +378,70 @@ func (a *BaseApplication) SystemConfig() *system.Configuration {
 }
 
 func (a *BaseApplication) BuildConfigurations() {
-	a.configurableFactory.Build(preConfigContainer, configContainer, postConfigContainer)
+	ctx := a.configurationConditionContext()
+	pre, preSkipped := filteredConfigurations(preConfigContainer, ctx)
+	cfg, cfgSkipped := filteredConfigurations(configContainer, ctx)
+	post, postSkipped := filteredConfigurations(postConfigContainer, ctx)
+
+	a.skippedConfigurations = nil
+	a.skippedConfigurations = append(a.skippedConfigurations, preSkipped...)
+	a.skippedConfigurations = append(a.skippedConfigurations, cfgSkipped...)
+	a.skippedConfigurations = append(a.skippedConfigurations, postSkipped...)
+
+	a.configurableFactory.Build(pre, cfg, post)
+}
+
+// SkippedConfigurations returns a factory.ConditionSkipped error for every
+// AutoConfiguration the most recent BuildConfigurations call dropped because
+// one of its Conditions didn't match
+func (a *BaseApplication) SkippedConfigurations() []error {
+	return a.skippedConfigurations
+}
+
+// configurationConditionContext builds the ConditionContext configuration
+// Conditions are evaluated against: which components (by name and by type)
+// are already registered, the factory's custom properties, and the
+// application's system config
+func (a *BaseApplication) configurationConditionContext() factory.ConditionContext {
+	registered := make(map[string]struct{}, len(instanceContainer.Items()))
+	registeredTypes := make(map[reflect.Type]struct{}, len(instanceContainer.Items()))
+	for name, inst := range instanceContainer.Items() {
+		registered[name] = struct{}{}
+		registeredTypes[reflect.TypeOf(inst)] = struct{}{}
+	}
+	var customProperties map[string]interface{}
+	if v, ok := a.instances.Get("instanceFactory"); ok {
+		if f, ok := v.(factory.InstantiateFactory); ok {
+			customProperties = f.CustomProperties()
+		}
+	}
+	return factory.NewConditionContext(a.systemConfig, customProperties, registered, registeredTypes)
+}
+
+// filteredConfigurations copies every configuration from container whose
+// Conditions match ctx into a fresh map, leaving container itself untouched —
+// preConfigContainer/configContainer/postConfigContainer are package-level
+// singletons shared by every BaseApplication in the process, so condition
+// filtering must not mutate them. It also returns a ConditionSkipped error
+// for each configuration it leaves out
+func filteredConfigurations(container cmap.ConcurrentMap, ctx factory.ConditionContext) (kept cmap.ConcurrentMap, skipped []error) {
+	kept = cmap.New()
+	for name, inst := range container.Items() {
+		matched := true
+		for _, cond := range ConfigurationConditions(name) {
+			if !cond.Matches(ctx) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			skipped = append(skipped, factory.NewError(factory.ConditionSkipped, name, errConfigurationConditionNotMatched))
+			log.Debugf("skipping configuration %v: condition not matched", name)
+			continue
+		}
+		kept.Set(name, inst)
+	}
+	return
 }
 
 func (a *BaseApplication) ConfigurableFactory() *autoconfigure.ConfigurableFactory {
@@ -225,6 +458,40 @@ func (a *BaseApplication) AfterInitialization(configs ...cmap.ConcurrentMap) {
 	a.postProcessor.AfterInitialization(a.configurableFactory)
 }
 
+// Run builds configurations, injects all registered components, starts any
+// Startable components in dependency order, then blocks until a SIGINT or
+// SIGTERM triggers Shutdown
+func (a *BaseApplication) Run() {
+	a.BeforeInitialization()
+	a.BuildConfigurations()
+	a.AfterInitialization()
+
+	a.rootCtx, a.cancelRoot = gocontext.WithCancel(gocontext.Background())
+	if err := a.configurableFactory.Start(a.rootCtx); err != nil {
+		log.Errorf("failed to start components: %v", err)
+	}
+
+	a.Use(RequestScopeMiddleware())
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	a.Shutdown(gocontext.Background())
+}
+
+// Shutdown cancels the application's root context and stops every
+// registered Stoppable component in reverse dependency order, each one
+// getting up to defaultShutdownTimeout to release its resources
+func (a *BaseApplication) Shutdown(ctx gocontext.Context) {
+	if a.cancelRoot != nil {
+		a.cancelRoot()
+	}
+	if a.configurableFactory != nil {
+		a.configurableFactory.Stop(ctx, defaultShutdownTimeout)
+	}
+}
+
 func (a *BaseApplication) RegisterController(controller interface{}) error {
 	return nil
 }
@@ -0,0 +1,158 @@
+// Copyright 2018 John Deng (hi.devops.io@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package factory
+
+import (
+	"fmt"
+	"reflect"
+
+	"hidevops.io/hiboot/pkg/system"
+)
+
+// ConditionContext exposes the state a Condition needs to decide whether the
+// component or configuration it guards should be registered
+type ConditionContext interface {
+	// SystemConfig returns the application's system configuration
+	SystemConfig() *system.Configuration
+	// CustomProperties returns the custom properties InstantiateFactory was
+	// initialized with
+	CustomProperties() map[string]interface{}
+	// ContainsComponent reports whether a component with name is already
+	// registered at the point the condition is evaluated
+	ContainsComponent(name string) bool
+	// ContainsComponentOfType reports whether a component of exactly type t
+	// is already registered at the point the condition is evaluated
+	ContainsComponentOfType(t reflect.Type) bool
+}
+
+// Condition gates registration of an AutoConfiguration or Component on some
+// runtime state, similar to Spring Boot's @Conditional
+type Condition interface {
+	Matches(ctx ConditionContext) bool
+}
+
+type conditionContext struct {
+	systemConfig     *system.Configuration
+	customProperties map[string]interface{}
+	registered       map[string]struct{}
+	registeredTypes  map[reflect.Type]struct{}
+}
+
+// NewConditionContext builds the ConditionContext that conditions are
+// evaluated against while building components
+func NewConditionContext(systemConfig *system.Configuration, customProperties map[string]interface{}, registered map[string]struct{}, registeredTypes map[reflect.Type]struct{}) ConditionContext {
+	return &conditionContext{
+		systemConfig:     systemConfig,
+		customProperties: customProperties,
+		registered:       registered,
+		registeredTypes:  registeredTypes,
+	}
+}
+
+func (c *conditionContext) SystemConfig() *system.Configuration {
+	return c.systemConfig
+}
+
+func (c *conditionContext) CustomProperties() map[string]interface{} {
+	return c.customProperties
+}
+
+func (c *conditionContext) ContainsComponent(name string) bool {
+	_, ok := c.registered[name]
+	return ok
+}
+
+func (c *conditionContext) ContainsComponentOfType(t reflect.Type) bool {
+	_, ok := c.registeredTypes[t]
+	return ok
+}
+
+type onPropertyCondition struct {
+	key           string
+	expectedValue string
+}
+
+// Matches matches when the custom property named key is set to expectedValue
+func (o *onPropertyCondition) Matches(ctx ConditionContext) bool {
+	v, ok := ctx.CustomProperties()[o.key]
+	if !ok {
+		return false
+	}
+	return fmt.Sprintf("%v", v) == o.expectedValue
+}
+
+// OnProperty builds a Condition that matches when the custom property named
+// key resolves to expectedValue
+func OnProperty(key, expectedValue string) Condition {
+	return &onPropertyCondition{key: key, expectedValue: expectedValue}
+}
+
+type onMissingBeanCondition struct {
+	name string
+}
+
+// Matches matches when no component named name is registered yet
+func (o *onMissingBeanCondition) Matches(ctx ConditionContext) bool {
+	return !ctx.ContainsComponent(o.name)
+}
+
+// OnMissingBean builds a Condition that matches when no component named name
+// has been registered yet, letting a fallback implementation back off once a
+// user supplies their own
+func OnMissingBean(name string) Condition {
+	return &onMissingBeanCondition{name: name}
+}
+
+type onMissingBeanTypeCondition struct {
+	typ reflect.Type
+}
+
+// Matches matches when no component of exactly o.typ is registered yet
+func (o *onMissingBeanTypeCondition) Matches(ctx ConditionContext) bool {
+	return !ctx.ContainsComponentOfType(o.typ)
+}
+
+// OnMissingBeanType builds a Condition that matches when no component of
+// the same type as instance has been registered yet. Unlike OnMissingBean it
+// doesn't depend on the fallback and the user-supplied bean sharing a name,
+// only a type
+func OnMissingBeanType(instance interface{}) Condition {
+	return &onMissingBeanTypeCondition{typ: reflect.TypeOf(instance)}
+}
+
+type onProfileCondition struct {
+	profiles []string
+}
+
+// Matches matches when the system's active profile is one of o.profiles
+func (o *onProfileCondition) Matches(ctx ConditionContext) bool {
+	systemConfig := ctx.SystemConfig()
+	if systemConfig == nil {
+		return false
+	}
+	active := systemConfig.App.Profiles.Active
+	for _, p := range o.profiles {
+		if p == active {
+			return true
+		}
+	}
+	return false
+}
+
+// OnProfile builds a Condition that matches when app.profiles.active is one
+// of profiles
+func OnProfile(profiles ...string) Condition {
+	return &onProfileCondition{profiles: profiles}
+}
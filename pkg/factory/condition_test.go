@@ -0,0 +1,58 @@
+// Copyright 2018 John Deng (hi.devops.io@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package factory
+
+import (
+	"reflect"
+	"testing"
+)
+
+type barComponent struct{}
+
+func TestOnMissingBeanMatchesUntilNameIsRegistered(t *testing.T) {
+	ctx := NewConditionContext(nil, nil, map[string]struct{}{"fooBean": {}}, nil)
+	if OnMissingBean("fooBean").Matches(ctx) {
+		t.Error("expected condition not to match, fooBean is already registered")
+	}
+	if !OnMissingBean("barBean").Matches(ctx) {
+		t.Error("expected condition to match, barBean is not registered")
+	}
+}
+
+func TestOnMissingBeanTypeMatchesUntilTypeIsRegistered(t *testing.T) {
+	registeredTypes := map[reflect.Type]struct{}{
+		reflect.TypeOf(new(barComponent)): {},
+	}
+	ctx := NewConditionContext(nil, nil, nil, registeredTypes)
+	if OnMissingBeanType(new(barComponent)).Matches(ctx) {
+		t.Error("expected condition not to match, a *barComponent is already registered")
+	}
+	if !OnMissingBeanType(new(fooComponent)).Matches(ctx) {
+		t.Error("expected condition to match, no *fooComponent is registered")
+	}
+}
+
+func TestOnPropertyMatchesExpectedValue(t *testing.T) {
+	ctx := NewConditionContext(nil, map[string]interface{}{"feature.enabled": "true"}, nil, nil)
+	if !OnProperty("feature.enabled", "true").Matches(ctx) {
+		t.Error("expected condition to match, feature.enabled is true")
+	}
+	if OnProperty("feature.enabled", "false").Matches(ctx) {
+		t.Error("expected condition not to match, feature.enabled is not false")
+	}
+	if OnProperty("feature.missing", "true").Matches(ctx) {
+		t.Error("expected condition not to match, feature.missing isn't set")
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2018 John Deng (hi.devops.io@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Code categorizes the kind of failure a factory.Error represents
+type Code string
+
+const (
+	// NotInitialized the InstantiateFactory was used before it was initialized
+	NotInitialized Code = "NotInitialized"
+	// InvalidType the object registered is not of a type the factory can handle
+	InvalidType Code = "InvalidType"
+	// DuplicateName another instance is already registered under the same name
+	DuplicateName Code = "DuplicateName"
+	// UnresolvedDependency the dependency graph could not be resolved
+	UnresolvedDependency Code = "UnresolvedDependency"
+	// InjectionFailed dependency injection into a function, method or object failed
+	InjectionFailed Code = "InjectionFailed"
+	// ConditionSkipped a component or configuration was skipped by a Condition
+	ConditionSkipped Code = "ConditionSkipped"
+)
+
+// Error is a structured error returned by InstantiateFactory operations. It
+// wraps Cause so that errors.Is, errors.As and errors.Unwrap all work as
+// expected against the underlying failure
+type Error struct {
+	Code      Code
+	Component string
+	Cause     error
+}
+
+// NewError builds a *Error with code for component, wrapping cause
+func NewError(code Code, component string, cause error) *Error {
+	return &Error{Code: code, Component: component, Cause: cause}
+}
+
+func (e *Error) Error() string {
+	if e.Component == "" {
+		return fmt.Sprintf("[factory] %s: %v", e.Code, e.Cause)
+	}
+	return fmt.Sprintf("[factory] %s: %s: %v", e.Code, e.Component, e.Cause)
+}
+
+// Unwrap exposes Cause for errors.Is/errors.As/errors.Unwrap
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// IsDuplicate reports whether err is a *Error with code DuplicateName, or a
+// *MultiError holding one
+func IsDuplicate(err error) bool {
+	return hasCode(err, DuplicateName)
+}
+
+// IsUnresolved reports whether err is a *Error with code
+// UnresolvedDependency, or a *MultiError holding one
+func IsUnresolved(err error) bool {
+	return hasCode(err, UnresolvedDependency)
+}
+
+// IsConditionSkipped reports whether err is a *Error with code
+// ConditionSkipped, or a *MultiError holding one
+func IsConditionSkipped(err error) bool {
+	return hasCode(err, ConditionSkipped)
+}
+
+func hasCode(err error, code Code) bool {
+	var multiErr *MultiError
+	if errors.As(err, &multiErr) {
+		for _, e := range multiErr.Errors {
+			if hasCode(e, code) {
+				return true
+			}
+		}
+		return false
+	}
+	var fErr *Error
+	if errors.As(err, &fErr) {
+		return fErr.Code == code
+	}
+	return false
+}
+
+// MultiError aggregates the failures encountered while building every
+// registered component in a single BuildComponents pass, instead of
+// abandoning the rest of the build on the first failure
+type MultiError struct {
+	Errors []error
+}
+
+// Append adds err to m, a nil err is ignored
+func (m *MultiError) Append(err error) {
+	if err != nil {
+		m.Errors = append(m.Errors, err)
+	}
+}
+
+// ErrorOrNil returns m if it holds at least one error, otherwise nil so
+// callers can assign the result straight to an error return value
+func (m *MultiError) ErrorOrNil() error {
+	if len(m.Errors) == 0 {
+		return nil
+	}
+	return m
+}
+
+func (m *MultiError) Error() string {
+	msgs := make([]string, len(m.Errors))
+	for i, err := range m.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("[factory] %d component(s) failed to build:\n%s", len(m.Errors), strings.Join(msgs, "\n"))
+}
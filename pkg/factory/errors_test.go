@@ -0,0 +1,65 @@
+// Copyright 2018 John Deng (hi.devops.io@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package factory
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorUnwrapsToCause(t *testing.T) {
+	cause := errors.New("boom")
+	err := NewError(DuplicateName, "fooBean", cause)
+	if !errors.Is(err, cause) {
+		t.Error("expected errors.Is to find the wrapped cause")
+	}
+}
+
+func TestIsDuplicateAndIsUnresolved(t *testing.T) {
+	dup := NewError(DuplicateName, "fooBean", errors.New("already taken"))
+	if !IsDuplicate(dup) {
+		t.Error("expected IsDuplicate to report true for a DuplicateName error")
+	}
+	if IsUnresolved(dup) {
+		t.Error("expected IsUnresolved to report false for a DuplicateName error")
+	}
+}
+
+func TestIsDuplicateLooksInsideMultiError(t *testing.T) {
+	multiErr := new(MultiError)
+	multiErr.Append(NewError(InjectionFailed, "barBean", errors.New("nope")))
+	multiErr.Append(NewError(DuplicateName, "fooBean", errors.New("already taken")))
+
+	if !IsDuplicate(multiErr.ErrorOrNil()) {
+		t.Error("expected IsDuplicate to find a DuplicateName error nested in a MultiError")
+	}
+}
+
+func TestMultiErrorErrorOrNil(t *testing.T) {
+	multiErr := new(MultiError)
+	if multiErr.ErrorOrNil() != nil {
+		t.Error("expected ErrorOrNil to return nil when no errors were appended")
+	}
+
+	multiErr.Append(nil)
+	if multiErr.ErrorOrNil() != nil {
+		t.Error("expected Append(nil) to be ignored")
+	}
+
+	multiErr.Append(errors.New("boom"))
+	if multiErr.ErrorOrNil() == nil {
+		t.Error("expected ErrorOrNil to return non-nil once an error was appended")
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 John Deng (hi.devops.io@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package factory defines the contract InstantiateFactory implementations
+// fulfill and the metadata they build components from
+package factory
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"hidevops.io/hiboot/pkg/system"
+	"hidevops.io/hiboot/pkg/system/types"
+)
+
+// InstantiateFactory is the factory that is responsible for object
+// instantiation, dependency injection, and the lifecycle of every
+// registered component
+type InstantiateFactory interface {
+	Initialized() bool
+	AppendComponent(c ...interface{})
+	BuildComponents() error
+	SetInstance(params ...interface{}) error
+	GetInstance(params ...interface{}) interface{}
+	GetInstances(name string) []interface{}
+	Items() map[string]interface{}
+	CustomProperties() map[string]interface{}
+	// SetSystemConfig makes systemConfig available to Condition evaluation
+	// during BuildComponents
+	SetSystemConfig(systemConfig *system.Configuration)
+	// Skipped returns a ConditionSkipped *Error for every component the most
+	// recent BuildComponents call dropped because one of its Conditions
+	// didn't match
+	Skipped() []error
+	// Start runs every registered Startable component, in dependency order
+	Start(ctx context.Context) error
+	// Stop runs every registered Stoppable component, in reverse dependency
+	// order, giving each one up to timeout to shut down
+	Stop(ctx context.Context, timeout time.Duration)
+}
+
+// MetaData holds everything InstantiateFactory needs to resolve, inject and
+// build a single registered component
+type MetaData struct {
+	Name      string
+	ShortName string
+	Type      reflect.Type
+	Kind      types.Kind
+	Object    interface{}
+	Context   interface{}
+
+	// DependsOn lists the names of the components this one depends on, it
+	// drives the build order depends.Resolve produces
+	DependsOn []string
+
+	// Scope controls how many times GetInstance builds this component, it
+	// defaults to ScopeSingleton
+	Scope Scope
+
+	// Conditions gates whether this component is built at all, it is
+	// dropped from the resolved build list when any of them doesn't match
+	Conditions []Condition
+}
+
+// NewMetaData builds a MetaData out of c, picking the name and scope out of
+// whichever of them are present and treating whatever is left as the
+// component's object
+func NewMetaData(c ...interface{}) *MetaData {
+	md := new(MetaData)
+	for _, p := range c {
+		switch v := p.(type) {
+		case string:
+			md.Name = v
+		case Scope:
+			md.Scope = v
+		case Condition:
+			md.Conditions = append(md.Conditions, v)
+		case []Condition:
+			md.Conditions = append(md.Conditions, v...)
+		default:
+			md.Object = v
+			md.Type = reflect.TypeOf(v)
+		}
+	}
+	if md.Name == "" && md.Object != nil {
+		md.Name = ParseObjectName(md.Object)
+	}
+	if md.ShortName == "" {
+		md.ShortName = md.Name
+	}
+	return md
+}
+
+// ParseObjectName derives a component name from its type, stripping the
+// leading pointer/slice decoration reflect.Type.String() adds
+func ParseObjectName(obj interface{}) string {
+	t := reflect.TypeOf(obj)
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// ParseParams pulls the name and instance out of params. It supports
+// ParseParams(name) to just look up a name, ParseParams(instance) to derive
+// a name from an unnamed instance, and ParseParams(name, instance) when both
+// are given explicitly
+func ParseParams(params ...interface{}) (name string, instance interface{}) {
+	switch len(params) {
+	case 1:
+		if s, ok := params[0].(string); ok {
+			name = s
+		} else {
+			instance = params[0]
+			name = ParseObjectName(instance)
+		}
+	case 2:
+		name, _ = params[0].(string)
+		instance = params[1]
+	}
+	return
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 John Deng (hi.devops.io@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package factory
+
+import "testing"
+
+type fooComponent struct{}
+
+func TestNewMetaDataDefaultsToSingletonScope(t *testing.T) {
+	md := NewMetaData(new(fooComponent))
+	if md.Scope != "" {
+		t.Errorf("expected no scope to be set by default, got %v", md.Scope)
+	}
+	if md.Name != "fooComponent" {
+		t.Errorf("expected name to be derived from the object's type, got %v", md.Name)
+	}
+}
+
+func TestNewMetaDataWithNameAndScope(t *testing.T) {
+	md := NewMetaData("myFoo", new(fooComponent), ScopePrototype)
+	if md.Name != "myFoo" {
+		t.Errorf("expected name myFoo, got %v", md.Name)
+	}
+	if md.Scope != ScopePrototype {
+		t.Errorf("expected scope %v, got %v", ScopePrototype, md.Scope)
+	}
+}
+
+func TestNewMetaDataWithConditions(t *testing.T) {
+	cond := OnMissingBean("fooBean")
+	md := NewMetaData("myFoo", new(fooComponent), cond)
+	if len(md.Conditions) != 1 || md.Conditions[0] != cond {
+		t.Errorf("expected Conditions to contain the given condition, got %v", md.Conditions)
+	}
+}
+
+func TestParseParamsNameOnly(t *testing.T) {
+	name, instance := ParseParams("myFoo")
+	if name != "myFoo" {
+		t.Errorf("expected name myFoo, got %v", name)
+	}
+	if instance != nil {
+		t.Errorf("expected no instance, got %v", instance)
+	}
+}
+
+func TestParseParamsNameAndInstance(t *testing.T) {
+	inst := new(fooComponent)
+	name, instance := ParseParams("myFoo", inst)
+	if name != "myFoo" {
+		t.Errorf("expected name myFoo, got %v", name)
+	}
+	if instance != inst {
+		t.Errorf("expected instance %v, got %v", inst, instance)
+	}
+}
@@ -16,12 +16,18 @@
 package instantiate
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
 	"hidevops.io/hiboot/pkg/factory"
 	"hidevops.io/hiboot/pkg/factory/depends"
 	"hidevops.io/hiboot/pkg/inject"
 	"hidevops.io/hiboot/pkg/log"
+	"hidevops.io/hiboot/pkg/system"
 	"hidevops.io/hiboot/pkg/system/types"
 	"hidevops.io/hiboot/pkg/utils/cmap"
 	"hidevops.io/hiboot/pkg/utils/reflector"
@@ -33,6 +39,8 @@ var (
 
 	// ErrInvalidObjectType invalid object type
 	ErrInvalidObjectType = errors.New("[factory] invalid object type")
+
+	errConditionNotMatched = errors.New("[factory] condition not matched")
 )
 
 // InstantiateFactory is the factory that responsible for object instantiation
@@ -41,6 +49,24 @@ type instantiateFactory struct {
 	components       []*factory.MetaData
 	categorized      map[string][]interface{}
 	customProperties cmap.ConcurrentMap
+	startables       []factory.Startable
+	stoppables       []factory.Stoppable
+	runningWg        sync.WaitGroup
+	systemConfig     *system.Configuration
+	skipped          []error
+}
+
+// SetSystemConfig makes systemConfig available to Condition evaluation
+// during BuildComponents
+func (f *instantiateFactory) SetSystemConfig(systemConfig *system.Configuration) {
+	f.systemConfig = systemConfig
+}
+
+// Skipped returns a ConditionSkipped *factory.Error for every component the
+// most recent BuildComponents call dropped because one of its Conditions
+// didn't match
+func (f *instantiateFactory) Skipped() []error {
+	return f.skipped
 }
 
 // NewInstantiateFactory the constructor of instantiateFactory
@@ -64,39 +90,57 @@ func (f *instantiateFactory) AppendComponent(c ...interface{}) {
 	f.components = append(f.components, metaData)
 }
 
-// BuildComponents build all registered components
+// BuildComponents build all registered components, collecting every
+// component's build failure into a *factory.MultiError instead of aborting
+// on the first one, so a single pass surfaces every broken wiring
 func (f *instantiateFactory) BuildComponents() (err error) {
 	// first resolve the dependency graph
 	var resolved []*factory.MetaData
 	log.Infof("Resolving dependencies")
-	resolved, err = depends.Resolve(f.components)
+	resolved, resolveErr := depends.Resolve(f.components)
+	if resolveErr != nil {
+		return factory.NewError(factory.UnresolvedDependency, "", resolveErr)
+	}
+	resolved = f.filterByConditions(resolved)
 	log.Infof("Injecting dependencies")
 	// then build components
+	multiErr := new(factory.MultiError)
 	var obj interface{}
 	var name string
 	for i, item := range resolved {
+		var buildErr error
 		// inject dependencies into function
 		// components, controllers
 		switch item.Kind {
 		case types.Func:
-			obj, err = inject.IntoFunc(item.Object)
+			obj, buildErr = inject.IntoFunc(item.Object)
 			name = item.Name
-			// TODO: should report error when err is not nil
-			if err == nil {
+			if buildErr == nil {
 				log.Debugf("%d: inject into func: %v %v", i, item.ShortName, item.Type)
 			}
 		case types.Method:
-			obj, err = inject.IntoMethod(item.Context, item.Object)
+			obj, buildErr = inject.IntoMethod(item.Context, item.Object)
 			name = item.Name
-			if err == nil {
+			if buildErr == nil {
 				log.Debugf("%d: inject into method: %v %v", i, item.ShortName, item.Type)
 			}
 		default:
 			name, obj = item.Name, item.Object
+			if obj == nil {
+				multiErr.Append(factory.NewError(factory.InvalidType, name, ErrInvalidObjectType))
+				continue
+			}
+		}
+		if buildErr != nil {
+			multiErr.Append(factory.NewError(factory.InjectionFailed, name, buildErr))
+			continue
 		}
 		if obj != nil {
 			// inject into object
-			err = inject.IntoObject(obj)
+			if injectErr := inject.IntoObject(obj); injectErr != nil {
+				multiErr.Append(factory.NewError(factory.InjectionFailed, name, injectErr))
+				continue
+			}
 			tagName, ok := reflector.FindEmbeddedFieldTag(obj, "Qualifier", "name")
 			if ok {
 				name = tagName
@@ -104,26 +148,196 @@ func (f *instantiateFactory) BuildComponents() (err error) {
 			}
 
 			if name != "" {
-				err = f.SetInstance(name, obj)
+				var setErr error
+				// singleton beans keep the object that was just built and
+				// injected above, non-singleton beans only use it to prove
+				// that the dependency graph resolves and store a factory
+				// closure instead so GetInstance can build a fresh copy
+				if item.Scope == factory.ScopePrototype || item.Scope == factory.ScopeRequest {
+					setErr = f.SetInstance(name, f.newInstanceFunc(item))
+				} else {
+					setErr = f.SetInstance(name, obj)
+					if setErr == nil {
+						// track Startable/Stoppable singletons in the same
+						// order dependency resolution produced them, so
+						// Start/Stop can walk it forwards/backwards
+						if s, ok := obj.(factory.Startable); ok {
+							f.startables = append(f.startables, s)
+						}
+						if s, ok := obj.(factory.Stoppable); ok {
+							f.stoppables = append(f.stoppables, s)
+						}
+					}
+				}
+				multiErr.Append(setErr)
 			}
 		}
 	}
-	if err == nil {
+	if err = multiErr.ErrorOrNil(); err == nil {
 		log.Infof("Injected dependencies")
 	}
 	return
 }
 
+// filterByConditions evaluates each item's Conditions and drops the ones
+// that don't match, then prunes any remaining component that only exists to
+// satisfy a dependency of a skipped one, repeating until nothing more can be
+// pruned
+func (f *instantiateFactory) filterByConditions(resolved []*factory.MetaData) []*factory.MetaData {
+	registered := make(map[string]struct{}, len(resolved))
+	registeredTypes := make(map[reflect.Type]struct{}, len(resolved))
+	ctx := factory.NewConditionContext(f.systemConfig, f.CustomProperties(), registered, registeredTypes)
+
+	kept := make([]*factory.MetaData, 0, len(resolved))
+	skipped := make(map[string]struct{})
+	f.skipped = nil
+	for _, item := range resolved {
+		// conditions are evaluated against only what has already been kept
+		// at this point in the build order, so e.g. OnMissingBean("mysqlRepo")
+		// on an in-memory fallback sees mysqlRepo as present only if mysqlRepo
+		// itself already matched its own conditions and was kept
+		if !conditionsMatch(item.Conditions, ctx) {
+			skipped[item.Name] = struct{}{}
+			f.skipped = append(f.skipped, factory.NewError(factory.ConditionSkipped, item.Name, errConditionNotMatched))
+			log.Debugf("skipping component %v: condition not matched", item.Name)
+			continue
+		}
+		kept = append(kept, item)
+		if item.Name != "" {
+			registered[item.Name] = struct{}{}
+		}
+		if item.Type != nil {
+			registeredTypes[item.Type] = struct{}{}
+		}
+	}
+
+	// index who depends on whom so a component kept only to satisfy a now
+	// skipped dependent can be pruned too
+	dependents := make(map[string][]string, len(resolved))
+	for _, item := range resolved {
+		for _, dep := range item.DependsOn {
+			dependents[dep] = append(dependents[dep], item.Name)
+		}
+	}
+
+	for pruning := true; pruning; {
+		pruning = false
+		next := make([]*factory.MetaData, 0, len(kept))
+		for _, item := range kept {
+			if onlyDependedOnBySkipped(item.Name, dependents, skipped) {
+				skipped[item.Name] = struct{}{}
+				log.Debugf("pruning component %v: only depended on by a skipped component", item.Name)
+				pruning = true
+				continue
+			}
+			next = append(next, item)
+		}
+		kept = next
+	}
+	return kept
+}
+
+// conditionsMatch reports whether every Condition in conditions matches ctx
+func conditionsMatch(conditions []factory.Condition, ctx factory.ConditionContext) bool {
+	for _, cond := range conditions {
+		if !cond.Matches(ctx) {
+			return false
+		}
+	}
+	return true
+}
+
+// onlyDependedOnBySkipped reports whether every known dependent of name has
+// already been skipped, meaning name itself no longer has a reason to exist
+func onlyDependedOnBySkipped(name string, dependents map[string][]string, skipped map[string]struct{}) bool {
+	deps, ok := dependents[name]
+	if !ok || len(deps) == 0 {
+		return false
+	}
+	for _, dependent := range deps {
+		if _, isSkipped := skipped[dependent]; !isSkipped {
+			return false
+		}
+	}
+	return true
+}
+
+// newInstanceFunc returns a factory.InstanceFunc that re-runs injection for
+// item, used to build a fresh instance for prototype and request scoped beans
+func (f *instantiateFactory) newInstanceFunc(item *factory.MetaData) factory.InstanceFunc {
+	return func() (obj interface{}, err error) {
+		switch item.Kind {
+		case types.Func:
+			obj, err = inject.IntoFunc(item.Object)
+		case types.Method:
+			obj, err = inject.IntoMethod(item.Context, item.Object)
+		default:
+			obj = item.Object
+		}
+		if err != nil {
+			return nil, err
+		}
+		if obj != nil {
+			err = inject.IntoObject(obj)
+		}
+		return
+	}
+}
+
+// Start starts every registered Startable component, in the dependency
+// order they were built in. Each Start runs in its own goroutine, tracked
+// by runningWg, since a Startable is expected to block for as long as its
+// background work runs and return only once ctx is cancelled
+func (f *instantiateFactory) Start(ctx context.Context) (err error) {
+	for _, s := range f.startables {
+		s := s
+		f.runningWg.Add(1)
+		go func() {
+			defer f.runningWg.Done()
+			if startErr := s.Start(ctx); startErr != nil {
+				log.Errorf("component failed to start: %v", startErr)
+			}
+		}()
+	}
+	return
+}
+
+// Stop stops every registered Stoppable component in reverse dependency
+// order, giving each one up to timeout to shut down before moving to the
+// next, then waits up to timeout for every Startable goroutine Start
+// launched to return, so a component that ignores ctx cancellation can't
+// hang shutdown forever
+func (f *instantiateFactory) Stop(ctx context.Context, timeout time.Duration) {
+	for i := len(f.stoppables) - 1; i >= 0; i-- {
+		stopCtx, cancel := context.WithTimeout(ctx, timeout)
+		if err := f.stoppables[i].Stop(stopCtx); err != nil {
+			log.Errorf("component failed to stop: %v", err)
+		}
+		cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		f.runningWg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Errorf("timed out after %v waiting for Startable goroutines to return", timeout)
+	}
+}
+
 // SetInstance save instance
 func (f *instantiateFactory) SetInstance(params ...interface{}) (err error) {
 	if !f.Initialized() {
-		return ErrNotInitialized
+		return factory.NewError(factory.NotInitialized, "", ErrNotInitialized)
 	}
 
 	name, instance := factory.ParseParams(params...)
 
 	if _, ok := f.instanceMap.Get(name); ok {
-		return fmt.Errorf("instance name %v is already taken", name)
+		return factory.NewError(factory.DuplicateName, name, fmt.Errorf("instance name %v is already taken", name))
 	}
 
 	f.instanceMap.Set(name, instance)
@@ -140,13 +354,24 @@ func (f *instantiateFactory) SetInstance(params ...interface{}) (err error) {
 	return
 }
 
-// GetInstance get instance by name
+// GetInstance get instance by name, a ctx may optionally be passed among
+// params to resolve request scoped beans against that request's instances
 func (f *instantiateFactory) GetInstance(params ...interface{}) (retVal interface{}) {
 	if !f.Initialized() {
 		return nil
 	}
 
-	name, _ := factory.ParseParams(params...)
+	var ctx context.Context
+	nameParams := make([]interface{}, 0, len(params))
+	for _, p := range params {
+		if c, ok := p.(context.Context); ok {
+			ctx = c
+			continue
+		}
+		nameParams = append(nameParams, p)
+	}
+
+	name, _ := factory.ParseParams(nameParams...)
 
 	//items := f.Items()
 	//log.Debug(items)
@@ -155,7 +380,33 @@ func (f *instantiateFactory) GetInstance(params ...interface{}) (retVal interfac
 	if retVal, ok = f.instanceMap.Get(name); !ok {
 		return nil
 	}
-	return
+
+	instFunc, ok := retVal.(factory.InstanceFunc)
+	if !ok {
+		return
+	}
+
+	if ctx != nil {
+		if reqInstances, hasCtx := factory.RequestInstances(ctx); hasCtx {
+			if cached, found := reqInstances.Get(name); found {
+				return cached
+			}
+			obj, err := instFunc()
+			if err != nil {
+				log.Errorf("failed to build request scoped instance %v: %v", name, err)
+				return nil
+			}
+			reqInstances.Set(name, obj)
+			return obj
+		}
+	}
+
+	obj, err := instFunc()
+	if err != nil {
+		log.Errorf("failed to build prototype instance %v: %v", name, err)
+		return nil
+	}
+	return obj
 }
 
 // GetInstances get instance by name
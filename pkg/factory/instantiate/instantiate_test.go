@@ -0,0 +1,211 @@
+// Copyright 2018 John Deng (hi.devops.io@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instantiate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"hidevops.io/hiboot/pkg/factory"
+	"hidevops.io/hiboot/pkg/utils/cmap"
+)
+
+// fakeLifecycleComponent's Start blocks until Stop closes stopCh, simulating
+// a worker that runs for the lifetime of the application
+type fakeLifecycleComponent struct {
+	startCalled   bool
+	startReturned bool
+	stopCalled    bool
+	started       chan struct{}
+	stopCh        chan struct{}
+}
+
+func newFakeLifecycleComponent() *fakeLifecycleComponent {
+	return &fakeLifecycleComponent{started: make(chan struct{}), stopCh: make(chan struct{})}
+}
+
+func (c *fakeLifecycleComponent) Start(ctx context.Context) error {
+	c.startCalled = true
+	close(c.started)
+	<-c.stopCh
+	c.startReturned = true
+	return nil
+}
+
+func (c *fakeLifecycleComponent) Stop(ctx context.Context) error {
+	c.stopCalled = true
+	close(c.stopCh)
+	return nil
+}
+
+func TestStopRunsStoppablesInReverseOrder(t *testing.T) {
+	first := newFakeLifecycleComponent()
+	second := newFakeLifecycleComponent()
+	var stopOrder []int
+
+	f := &instantiateFactory{
+		stoppables: []factory.Stoppable{
+			stopOrderRecorder{fakeLifecycleComponent: first, id: 1, order: &stopOrder},
+			stopOrderRecorder{fakeLifecycleComponent: second, id: 2, order: &stopOrder},
+		},
+	}
+
+	f.Stop(context.Background(), time.Second)
+
+	if !first.stopCalled || !second.stopCalled {
+		t.Fatal("expected both components to be stopped")
+	}
+	if len(stopOrder) != 2 || stopOrder[0] != 2 || stopOrder[1] != 1 {
+		t.Errorf("expected components to stop in reverse order, got %v", stopOrder)
+	}
+}
+
+func TestFactoryStartDoesNotBlockWhileComponentStartBlocks(t *testing.T) {
+	comp := newFakeLifecycleComponent()
+	f := &instantiateFactory{startables: []factory.Startable{comp}}
+	defer close(comp.stopCh)
+
+	done := make(chan error, 1)
+	go func() { done <- f.Start(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected Start to succeed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("factory Start blocked, even though each Startable runs in its own goroutine")
+	}
+	// the component's Start runs in a goroutine of its own, so it may be
+	// called only after the factory's Start has returned
+	select {
+	case <-comp.started:
+	case <-time.After(time.Second):
+		t.Error("expected the component's Start to have been called")
+	}
+}
+
+func TestStopWaitsForStartGoroutineToReturn(t *testing.T) {
+	comp := newFakeLifecycleComponent()
+	f := &instantiateFactory{
+		startables: []factory.Startable{comp},
+		stoppables: []factory.Stoppable{comp},
+	}
+	if err := f.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		f.Stop(context.Background(), time.Second)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return after stopping the component")
+	}
+	if !comp.stopCalled {
+		t.Error("expected Stop to have been called")
+	}
+	if !comp.startReturned {
+		t.Error("Stop returned without waiting for the Start goroutine it launched to return")
+	}
+}
+
+// stubbornComponent's Start never returns, even after ctx is cancelled,
+// simulating a component that ignores the shutdown signal
+type stubbornComponent struct{}
+
+func (stubbornComponent) Start(ctx context.Context) error {
+	select {}
+}
+
+func TestStopReturnsAfterTimeoutWhenAStartableNeverReturns(t *testing.T) {
+	f := &instantiateFactory{startables: []factory.Startable{stubbornComponent{}}}
+	if err := f.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		f.Stop(context.Background(), 50*time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop hung forever instead of giving up after its timeout")
+	}
+}
+
+type stopOrderRecorder struct {
+	*fakeLifecycleComponent
+	id    int
+	order *[]int
+}
+
+func (s stopOrderRecorder) Stop(ctx context.Context) error {
+	*s.order = append(*s.order, s.id)
+	return s.fakeLifecycleComponent.Stop(ctx)
+}
+
+func TestGetInstancePrototypeBuildsFreshInstanceEveryCall(t *testing.T) {
+	calls := 0
+	instFunc := factory.InstanceFunc(func() (interface{}, error) {
+		calls++
+		return calls, nil
+	})
+	f := &instantiateFactory{instanceMap: cmap.New()}
+	f.instanceMap.Set("fooBean", instFunc)
+
+	first := f.GetInstance("fooBean")
+	second := f.GetInstance("fooBean")
+	if first == second {
+		t.Errorf("expected a fresh instance on every call for prototype scope, got %v and %v", first, second)
+	}
+	if calls != 2 {
+		t.Errorf("expected instFunc to run once per call, ran %d times", calls)
+	}
+}
+
+func TestGetInstanceRequestScopeCachesWithinOneRequestContext(t *testing.T) {
+	calls := 0
+	instFunc := factory.InstanceFunc(func() (interface{}, error) {
+		calls++
+		return calls, nil
+	})
+	f := &instantiateFactory{instanceMap: cmap.New()}
+	f.instanceMap.Set("fooBean", instFunc)
+
+	ctx := factory.NewRequestContext(context.Background())
+	first := f.GetInstance(ctx, "fooBean")
+	second := f.GetInstance(ctx, "fooBean")
+	if first != second {
+		t.Errorf("expected the same instance within one request context, got %v and %v", first, second)
+	}
+	if calls != 1 {
+		t.Errorf("expected instFunc to run exactly once, ran %d times", calls)
+	}
+
+	otherCtx := factory.NewRequestContext(context.Background())
+	third := f.GetInstance(otherCtx, "fooBean")
+	if third == first {
+		t.Error("expected a different request context to get its own fresh instance")
+	}
+}
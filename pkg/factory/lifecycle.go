@@ -0,0 +1,33 @@
+// Copyright 2018 John Deng (hi.devops.io@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package factory
+
+import "context"
+
+// Startable is implemented by a component that needs to run background
+// work (a worker, a poller, a reporter) for the lifetime of the
+// application. InstantiateFactory runs each Startable's Start in its own
+// goroutine, so Start is free to block for as long as its background work
+// needs to run; it should return once ctx is cancelled, or the work is
+// otherwise done
+type Startable interface {
+	Start(ctx context.Context) error
+}
+
+// Stoppable is implemented by a component that needs to release resources
+// or wind down background work during graceful shutdown
+type Stoppable interface {
+	Stop(ctx context.Context) error
+}
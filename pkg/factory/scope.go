@@ -0,0 +1,57 @@
+// Copyright 2018 John Deng (hi.devops.io@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package factory
+
+import (
+	"context"
+
+	"hidevops.io/hiboot/pkg/utils/cmap"
+)
+
+// Scope represents the lifecycle of a bean managed by InstantiateFactory
+type Scope string
+
+const (
+	// ScopeSingleton is the default scope, the instance is built once and shared
+	// for the lifetime of the application
+	ScopeSingleton Scope = "singleton"
+
+	// ScopePrototype causes GetInstance to build a brand new instance on every call
+	ScopePrototype Scope = "prototype"
+
+	// ScopeRequest ties the instance lifetime to a single http request, the
+	// instance is built at most once per request and discarded afterwards
+	ScopeRequest Scope = "request"
+)
+
+// InstanceFunc builds a fresh instance of a non-singleton scoped bean, it is
+// what instanceMap holds for prototype and request scoped components instead
+// of the built object itself
+type InstanceFunc func() (interface{}, error)
+
+type requestInstancesKey struct{}
+
+// NewRequestContext returns a copy of ctx that carries a fresh store for
+// request scoped instances, it should be called once per incoming http request
+func NewRequestContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestInstancesKey{}, cmap.New())
+}
+
+// RequestInstances returns the request scoped instance store carried by ctx,
+// ok is false when ctx was not created with NewRequestContext
+func RequestInstances(ctx context.Context) (instances cmap.ConcurrentMap, ok bool) {
+	instances, ok = ctx.Value(requestInstancesKey{}).(cmap.ConcurrentMap)
+	return
+}
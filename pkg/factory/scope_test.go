@@ -0,0 +1,49 @@
+// Copyright 2018 John Deng (hi.devops.io@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package factory
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNewRequestContextCarriesAFreshInstanceStore(t *testing.T) {
+	ctx := NewRequestContext(context.Background())
+	store, ok := RequestInstances(ctx)
+	if !ok {
+		t.Fatal("expected RequestInstances to find a store on a request context")
+	}
+	store.Set("fooBean", 42)
+	v, found := store.Get("fooBean")
+	if !found || v != 42 {
+		t.Errorf("expected to get back the value set on the request store, got %v, %v", v, found)
+	}
+}
+
+func TestRequestInstancesNotFoundOnAPlainContext(t *testing.T) {
+	if _, ok := RequestInstances(context.Background()); ok {
+		t.Error("expected RequestInstances to report false for a context without a request store")
+	}
+}
+
+func TestNewRequestContextGivesEachRequestItsOwnStore(t *testing.T) {
+	first, _ := RequestInstances(NewRequestContext(context.Background()))
+	second, _ := RequestInstances(NewRequestContext(context.Background()))
+
+	first.Set("fooBean", "first")
+	if _, found := second.Get("fooBean"); found {
+		t.Error("expected two separate request contexts not to share a store")
+	}
+}